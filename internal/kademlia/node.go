@@ -4,15 +4,55 @@ import (
 	"crypto/sha1"
 	"fmt"
 	"math/rand"
+	"sort"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
+// alpha is the Kademlia concurrency parameter: the number of unqueried
+// contacts dispatched per lookup round.
+const alpha = 3
+
+// rpcTimeout bounds how long a single lookup RPC may take before the
+// contact it was sent to is treated as failed.
+const rpcTimeout = 300 * time.Millisecond
+
+// valueTTL is how long a stored value lives before it expires.
+const valueTTL = 24 * time.Hour
+
+// republishInterval is how often a node re-STOREs the values it
+// originally published.
+const republishInterval = 24 * time.Hour
+
+// replicateInterval is how often a node re-STOREs every value it
+// currently holds to the current k closest contacts, to handle churn.
+const replicateInterval = time.Hour
+
+// bucketRefreshWindow is how long a bucket may go without a lookup
+// before refreshNodes considers it due for a refresh.
+const bucketRefreshWindow = time.Hour
+
+// bucketRefreshInterval is how often refreshLoop checks for buckets
+// due for a refresh.
+const bucketRefreshInterval = 10 * time.Minute
+
+// storedValue is a single entry in a node's local content store.
+type storedValue struct {
+	Data      string
+	Expires   time.Time
+	Published bool
+}
+
 type Node struct {
 	RT      *RoutingTable
 	network Network
-	content map[string]string
+	db      *NodeDB
+
+	mu              sync.Mutex
+	content         map[string]*storedValue
+	bucketRefreshed map[int]time.Time
 }
 
 // InitNode initializes the Kademlia Node
@@ -38,140 +78,610 @@ func (kademlia *Node) InitNode() {
 	me := NewContact(id, ip+":8080")
 	kademlia.RT = NewRoutingTable(me)
 
+	db, err := NewNodeDB(nodeDBPath(id))
+	if err != nil {
+		log.Warn(err)
+		db, _ = NewNodeDB("")
+	}
+	kademlia.db = db
+	kademlia.db.RunCompaction(time.Hour, nodeDBCompactTTL, nil)
+	kademlia.db.RunPersist(nodeDBFlushInterval, nil)
+	kademlia.content = make(map[string]*storedValue)
+	kademlia.bucketRefreshed = make(map[int]time.Time)
+
+	// seed the routing table from contacts we already know to be alive,
+	// rather than relying solely on the hardcoded rendezvous node
+	for _, c := range kademlia.db.LiveContacts() {
+		kademlia.handleAddContact(c)
+	}
+
 	rendezvousNode := NewContact(rendezvousID, "10.0.8.3:8080")
 	kademlia.JoinNetwork(rendezvousNode)
 
-	kademlia.content = make(map[string]string)
+	go kademlia.republishLoop()
+	go kademlia.replicateLoop()
+	go kademlia.refreshLoop()
 }
 
-func (kademlia *Node) NodeLookup(target *Contact) {
+// nodeDBPath returns the on-disk location of this node's persistent
+// node database.
+func nodeDBPath(id *NodeID) string {
+	return "nodedb_" + id.String() + ".db"
+}
 
-	// TODO: support for parallelism alpha = ~3
-	shortList := kademlia.RT.FindClosestContacts(target.ID, BucketSize)
+// lookupState tracks where a contact is in an iterative lookup round.
+type lookupState int
 
-	closestNode := shortList[0]
-	// fmt.Println("table = ", closestNode)
+const (
+	stateUnqueried lookupState = iota
+	stateInFlight
+	stateResponded
+	stateFailed
+)
+
+// findResult is what a single FIND_NODE/FIND_VALUE RPC reports back to
+// the round collector, win or lose.
+type findResult struct {
+	contact  Contact
+	contacts []Contact
+	value    *string
+	ok       bool
+}
+
+// NodeLookup performs the standard iterative Kademlia node lookup for
+// target: each round it sends FIND_NODE to up to alpha unqueried
+// contacts from the shortlist concurrently, merges the contacts they
+// return back into the shortlist (deduped, sorted by distance to
+// target, capped at BucketSize), and stops once a round fails to
+// surface a strictly closer contact or every contact in the shortlist
+// has been queried. Every contact that responds, and every contact it
+// reports back, is also fed into handleAddContact so the lookup
+// actually populates the routing table, not just the local shortlist.
+// It returns the final shortlist of k closest contacts.
+func (kademlia *Node) NodeLookup(target *Contact) []Contact {
+	shortlist := kademlia.RT.FindClosestContacts(target.ID, BucketSize)
+	if len(shortlist) == 0 {
+		return shortlist
+	}
+	sortByDistance(shortlist, target.ID)
+
+	state := newLookupStateMap(shortlist)
+	closest := shortlist[0]
 
 	for {
-		fmt.Println("table = ", shortList)
+		round := pickUnqueried(shortlist, state, alpha)
+		if len(round) == 0 {
+			break
+		}
+
+		results := make(chan findResult, len(round))
+		for _, c := range round {
+			state[c.ID.String()] = stateInFlight
+			go func(c Contact) {
+				rpc, err := kademlia.sendFindContact(c)
+				if err != nil {
+					results <- findResult{contact: c}
+					return
+				}
+				results <- findResult{contact: c, contacts: rpc.Payload.Contacts, ok: true}
+			}(c)
+		}
 
-		if shortList[0].ID.Equals(target.ID) {
-			fmt.Println("node found = ", closestNode)
+		for range round {
+			res := <-results
+			if !res.ok {
+				state[res.contact.ID.String()] = stateFailed
+				kademlia.onFindFailure(res.contact)
+				continue
+			}
+			state[res.contact.ID.String()] = stateResponded
+			kademlia.db.ResetFindFail(res.contact.ID.String())
+			kademlia.handleAddContact(res.contact)
+			for _, c := range res.contacts {
+				markKnown(state, c)
+				shortlist = appendUnique(shortlist, c)
+				kademlia.handleAddContact(c)
+			}
+		}
+
+		shortlist = capClosest(shortlist, target.ID)
+
+		if !shortlist[0].Less(&closest) || allQueried(shortlist, state) {
 			break
+		}
+		closest = shortlist[0]
+	}
+
+	return shortlist
+}
 
-		} else {
+// IterativeFindValue performs an iterative FIND_VALUE lookup for hash,
+// behaving like NodeLookup but short-circuiting as soon as any queried
+// contact returns a value. When that happens the value is cached (via
+// STORE) at the closest already-queried contact that did not have it,
+// the standard Kademlia caching optimization.
+func (kademlia *Node) IterativeFindValue(hash string) (*string, []Contact) {
+	target := NewContact(NewNodeID(hash), "")
+
+	shortlist := kademlia.RT.FindClosestContacts(target.ID, BucketSize)
+	if len(shortlist) == 0 {
+		return nil, shortlist
+	}
+	sortByDistance(shortlist, target.ID)
 
-			rpc, err := kademlia.network.SendFindContactMessage(&shortList[0], &kademlia.RT.me)
+	state := newLookupStateMap(shortlist)
+	closest := shortlist[0]
+	var queriedWithoutValue []Contact
 
-			// remove current/first node from shorttable
-			if len(shortList) > 0 {
-				shortList = shortList[1:]
-			}
+	for {
+		round := pickUnqueried(shortlist, state, alpha)
+		if len(round) == 0 {
+			break
+		}
+
+		results := make(chan findResult, len(round))
+		for _, c := range round {
+			state[c.ID.String()] = stateInFlight
+			go func(c Contact) {
+				rpc, err := kademlia.sendFindValue(hash, c)
+				if err != nil {
+					results <- findResult{contact: c}
+					return
+				}
+				results <- findResult{contact: c, contacts: rpc.Payload.Contacts, value: rpc.Payload.Value, ok: true}
+			}(c)
+		}
 
-			// append contacts to shortlist if err is none
-			if err == nil {
-				for i := 0; i < len(rpc.Payload.Contacts); i++ {
-					shortList = appendUnique(shortList, rpc.Payload.Contacts[i])
+		for range round {
+			res := <-results
+			if !res.ok {
+				state[res.contact.ID.String()] = stateFailed
+				kademlia.onFindFailure(res.contact)
+				continue
+			}
+			state[res.contact.ID.String()] = stateResponded
+			kademlia.db.ResetFindFail(res.contact.ID.String())
+			kademlia.handleAddContact(res.contact)
+
+			if res.value != nil {
+				if len(queriedWithoutValue) > 0 {
+					sortByDistance(queriedWithoutValue, target.ID)
+					cacheAt := queriedWithoutValue[0]
+					go kademlia.network.SendStoreMessage(*res.value, &cacheAt, &kademlia.RT.me)
 				}
+				return res.value, shortlist
+			}
+			queriedWithoutValue = append(queriedWithoutValue, res.contact)
+
+			for _, c := range res.contacts {
+				markKnown(state, c)
+				shortlist = appendUnique(shortlist, c)
+				kademlia.handleAddContact(c)
 			}
+		}
+
+		shortlist = capClosest(shortlist, target.ID)
+		if !shortlist[0].Less(&closest) || allQueried(shortlist, state) {
+			break
+		}
+		closest = shortlist[0]
+	}
+
+	return nil, shortlist
+}
+
+// sendFindContact issues a FIND_NODE RPC with a per-RPC timeout; a
+// contact that doesn't answer in time is reported as failed.
+func (kademlia *Node) sendFindContact(c Contact) (*RPC, error) {
+	type result struct {
+		rpc *RPC
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		rpc, err := kademlia.network.SendFindContactMessage(&c, &kademlia.RT.me)
+		done <- result{rpc, err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.rpc, res.err
+	case <-time.After(rpcTimeout):
+		return nil, fmt.Errorf("find contact rpc to %s timed out", c.ID)
+	}
+}
+
+// sendFindValue issues a FIND_VALUE RPC with a per-RPC timeout; a
+// contact that doesn't answer in time is reported as failed.
+func (kademlia *Node) sendFindValue(hash string, c Contact) (*RPC, error) {
+	type result struct {
+		rpc *RPC
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		rpc, err := kademlia.network.SendFindValueMessage(hash, &c, &kademlia.RT.me)
+		done <- result{rpc, err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.rpc, res.err
+	case <-time.After(rpcTimeout):
+		return nil, fmt.Errorf("find value rpc to %s timed out", c.ID)
+	}
+}
+
+// onFindFailure records a lookup RPC failure for contact in the node
+// database and evicts it from the routing table once it has failed
+// nodeDBEvictThreshold times in a row.
+func (kademlia *Node) onFindFailure(contact Contact) {
+	if kademlia.db.IncFindFail(contact.ID.String()) {
+		kademlia.RT.RemoveContact(contact)
+	}
+}
+
+// newLookupStateMap seeds a lookup-state map from an initial shortlist.
+func newLookupStateMap(shortlist []Contact) map[string]lookupState {
+	state := make(map[string]lookupState, len(shortlist))
+	for _, c := range shortlist {
+		state[c.ID.String()] = stateUnqueried
+	}
+	return state
+}
+
+// markKnown registers a newly-seen contact as unqueried unless it is
+// already tracked.
+func markKnown(state map[string]lookupState, c Contact) {
+	if _, known := state[c.ID.String()]; !known {
+		state[c.ID.String()] = stateUnqueried
+	}
+}
 
-			// update closest node if first element distance is shorter
-			if len(shortList) > 0 || shortList[0].Less(target) {
-				closestNode = shortList[0]
+// pickUnqueried returns up to n contacts from shortlist that have not
+// yet been queried, in shortlist order (closest first).
+func pickUnqueried(shortlist []Contact, state map[string]lookupState, n int) []Contact {
+	picked := make([]Contact, 0, n)
+	for _, c := range shortlist {
+		if state[c.ID.String()] == stateUnqueried {
+			picked = append(picked, c)
+			if len(picked) == n {
+				break
 			}
+		}
+	}
+	return picked
+}
 
-			// sleep for testing
-			time.Sleep(1000 * time.Millisecond)
+// allQueried reports whether every contact in shortlist has either
+// responded or failed.
+func allQueried(shortlist []Contact, state map[string]lookupState) bool {
+	for _, c := range shortlist {
+		s := state[c.ID.String()]
+		if s != stateResponded && s != stateFailed {
+			return false
 		}
 	}
+	return true
+}
+
+// capClosest sorts shortlist by XOR distance to target and truncates
+// it to the BucketSize closest contacts.
+func capClosest(shortlist []Contact, target *NodeID) []Contact {
+	sortByDistance(shortlist, target)
+	if len(shortlist) > BucketSize {
+		shortlist = shortlist[:BucketSize]
+	}
+	return shortlist
+}
+
+// sortByDistance recalculates each contact's distance to target and
+// sorts the slice closest-first.
+func sortByDistance(contacts []Contact, target *NodeID) {
+	for i := range contacts {
+		contacts[i].CalcDistance(target)
+	}
+	sort.Slice(contacts, func(i, j int) bool {
+		return contacts[i].Less(&contacts[j])
+	})
 }
 
 func appendUnique(slice []Contact, i Contact) []Contact {
 	for _, ele := range slice {
-		if ele == i {
+		if ele.ID.Equals(i.ID) {
 			return slice
 		}
 	}
 
-	return append([]Contact{i}, slice...)
+	return append(slice, i)
 }
 
+// FindValue looks for hash in the local store first and, if it isn't
+// held locally, performs an iterative FIND_VALUE lookup across the
+// network. hash must already be the hex-encoded SHA-1 digest returned
+// by hashKey/StoreValue, not raw content — it is used directly as a
+// NodeID target, the same contract replicateToClosest relies on.
 func (kademlia *Node) FindValue(hash string) {
-	sha1 := sha1.Sum([]byte(hash))
-	var content = kademlia.content[string(sha1[:])]
-	if content == "" {
+	if value := kademlia.SearchStore(hash); value != nil {
+		fmt.Println("Content = ", *value)
+		return
+	}
+
+	value, _ := kademlia.IterativeFindValue(hash)
+	if value == nil {
 		fmt.Println("Content not found!")
-	} else {
-		// return content
-		fmt.Println("Content = ", content)
+		return
 	}
-	// return content
+	fmt.Println("Content = ", *value)
 }
 
+// StoreValue hashes data, stores it locally as the original publisher,
+// and replicates it via STORE RPCs to the k closest live contacts to
+// its hash.
 func (kademlia *Node) StoreValue(data string) {
-	sha1 := sha1.Sum([]byte(data))
-	kademlia.content[string(sha1[:])] = data
+	key := hashKey(data)
+	kademlia.storeLocal(key, data, true, time.Now().Add(valueTTL))
+	kademlia.replicateToClosest(key, data)
+}
+
+// HandleStoreRPC stores a value received via an inbound STORE RPC from
+// another node. Unlike StoreValue, this node is not treated as the
+// original publisher, so it will replicate but never republish the
+// value on the node's behalf. This is the handler Network's STORE
+// receive path must call with the RPC's key/value once it decodes an
+// inbound STORE — that wiring lives in network.go, which is not part
+// of this chunk.
+func (kademlia *Node) HandleStoreRPC(key, value string) {
+	kademlia.storeLocal(key, value, false, time.Now().Add(valueTTL))
+}
+
+// hashKey returns the hex-encoded SHA-1 hash used as both the content
+// key and the NodeID target for lookups of data.
+func hashKey(data string) string {
+	sum := sha1.Sum([]byte(data))
+	return fmt.Sprintf("%x", sum)
+}
+
+// storeLocal writes value into the local content store under key,
+// recording its expiration and whether this node originally published
+// it.
+func (kademlia *Node) storeLocal(key, value string, published bool, expires time.Time) {
+	kademlia.mu.Lock()
+	defer kademlia.mu.Unlock()
+
+	kademlia.content[key] = &storedValue{
+		Data:      value,
+		Expires:   expires,
+		Published: published,
+	}
+}
+
+// replicateToClosest runs an iterative node lookup for key and issues
+// STORE RPCs to the k closest contacts returned.
+func (kademlia *Node) replicateToClosest(key, data string) {
+	target := NewContact(NewNodeID(key), "")
+	closest := kademlia.NodeLookup(&target)
+	for _, c := range closest {
+		go kademlia.network.SendStoreMessage(data, &c, &kademlia.RT.me)
+	}
 }
 
 func (kademlia *Node) Ping() {
-	target := &kademlia.RT.FindClosestContacts(kademlia.RT.me.ID, BucketSize)[0]
+	closest := kademlia.RT.FindClosestContacts(kademlia.RT.me.ID, BucketSize)
+	if len(closest) == 0 {
+		return
+	}
+	target := &closest[0]
+
+	if kademlia.db.IsBonded(target.ID.String()) {
+		// already heard a pong from this contact recently, no need to re-ping
+		return
+	}
+
+	kademlia.db.RecordPing(target.ID.String(), target.Address)
 	rpc, err := kademlia.network.SendPingMessage(target, &kademlia.RT.me)
 
 	if err != nil {
 		log.Warn(err)
-		kademlia.RT.RemoveContact(*target)
+		kademlia.RT.Replace(*target)
 	} else if *rpc.Type == "OK" {
-		kademlia.RT.AddContact(*target)
+		kademlia.db.RecordPong(target.ID.String(), target.Address)
+		kademlia.RT.Touch(*target)
 	}
 }
 
+// handleAddContact adds contact to the routing table, applying the
+// discv5-style liveness policy: if contact's bucket is full (AddContact
+// returns Cached), the bucket's least-recently-seen live contact is
+// pinged. If it responds, it is kept and moved to the
+// most-recently-seen position; if it fails to respond, it is evicted
+// and replaced by the most recently cached contact, and contact itself
+// is added.
+func (kademlia *Node) handleAddContact(contact Contact) {
+	if kademlia.RT.AddContact(contact) != Cached {
+		return
+	}
+
+	lru, ok := kademlia.RT.LeastRecentlySeen(contact.ID)
+	if !ok {
+		return
+	}
+
+	rpc, err := kademlia.network.SendPingMessage(&lru, &kademlia.RT.me)
+	if err != nil || *rpc.Type != OK {
+		kademlia.RT.Replace(lru)
+		kademlia.RT.AddContact(contact)
+		return
+	}
+	kademlia.RT.Touch(lru)
+}
+
 // SearchStore looks for a value in the node's store. Returns the value
-// if found else nil.
+// if found and not expired, else nil.
 func (kademlia *Node) SearchStore(key string) *string {
-	value, exists := kademlia.content[key]
-	if exists {
+	kademlia.mu.Lock()
+	defer kademlia.mu.Unlock()
+
+	stored, exists := kademlia.content[key]
+	if !exists || time.Now().After(stored.Expires) {
 		return nil
 	}
+	value := stored.Data
 	return &value
 }
 
-// generate a random ID that is inside a given bucket
-func generateRefreshNodeValue(bucketIndex int, seed int64) *NodeID {
-	bytePos := 19 - (bucketIndex / 8) // position of the highest byte of the ID
-	offset := bucketIndex % 8
+// republishLoop re-issues STORE for every key this node originally
+// published, every republishInterval, so published values survive
+// churn in the nodes that hold them.
+func (kademlia *Node) republishLoop() {
+	ticker := time.NewTicker(republishInterval)
+	for range ticker.C {
+		for key, data := range kademlia.publishedSnapshot() {
+			kademlia.replicateToClosest(key, data)
+		}
+	}
+}
 
-	nodeValue := NewNodeID("0000000000000000000000000000000000000000")
+// replicateLoop re-STOREs every key this node currently holds (whether
+// it published it or not) to the current k closest contacts every
+// replicateInterval, to handle churn, and expires keys past their TTL.
+func (kademlia *Node) replicateLoop() {
+	ticker := time.NewTicker(replicateInterval)
+	for range ticker.C {
+		for key, data := range kademlia.liveSnapshot() {
+			kademlia.replicateToClosest(key, data)
+		}
+	}
+}
+
+// publishedSnapshot returns a key->data snapshot of unexpired content
+// this node originally published.
+func (kademlia *Node) publishedSnapshot() map[string]string {
+	kademlia.mu.Lock()
+	defer kademlia.mu.Unlock()
+
+	snapshot := make(map[string]string)
+	now := time.Now()
+	for key, stored := range kademlia.content {
+		if stored.Published && now.Before(stored.Expires) {
+			snapshot[key] = stored.Data
+		}
+	}
+	return snapshot
+}
 
-	t := 0
-	t = 1 << offset
+// liveSnapshot returns a key->data snapshot of all unexpired content,
+// evicting anything that has expired.
+func (kademlia *Node) liveSnapshot() map[string]string {
+	kademlia.mu.Lock()
+	defer kademlia.mu.Unlock()
+
+	snapshot := make(map[string]string)
+	now := time.Now()
+	for key, stored := range kademlia.content {
+		if now.After(stored.Expires) {
+			delete(kademlia.content, key)
+			continue
+		}
+		snapshot[key] = stored.Data
+	}
+	return snapshot
+}
+
+// generateRefreshNodeValue generates a random NodeID whose XOR distance
+// to me falls exactly inside bucket bucketIndex: it builds a distance
+// pattern with the bit at bucketIndex set and every bit below it
+// randomized, then XORs that pattern with me so the result's actual
+// distance from me is what lands it in that bucket.
+func generateRefreshNodeValue(me *NodeID, bucketIndex int, seed int64) *NodeID {
+	bytePos := 19 - (bucketIndex / 8) // position of the highest byte of the distance
+	offset := bucketIndex % 8
 
-	nodeValue[bytePos] = byte(t)
-	rand.Seed(int64(seed))
+	distance := NewNodeID("0000000000000000000000000000000000000000")
+	distance[bytePos] = byte(1 << uint(offset))
 
-	// generate a random byte for each byte position from the end of the string to the bytePos
+	r := rand.New(rand.NewSource(seed))
+	// randomize the bits below the set bit within bytePos, then every byte
+	// position below bytePos (together, every bit below bucketIndex)
+	distance[bytePos] |= byte(r.Intn(1 << uint(offset)))
 	for i := 19; i > bytePos; i-- {
-		scew := uint8(rand.Intn(bucketIndex))
-		nodeValue[i] ^= byte(scew)
+		distance[i] = byte(r.Intn(256))
+	}
+
+	nodeValue := NewNodeID("0000000000000000000000000000000000000000")
+	for i := 0; i < IDLength; i++ {
+		nodeValue[i] = me[i] ^ distance[i]
 	}
 
 	return nodeValue
 }
 
+// refreshNodes runs a NodeLookup targeting a random ID in every bucket
+// that hasn't been refreshed within bucketRefreshWindow, so buckets
+// stay populated even when nothing nearby is actively being looked up.
+// The actual population happens inside NodeLookup itself, which feeds
+// every contact it discovers through handleAddContact; refreshNodes
+// only has to make sure a lookup lands in each bucket.
 func (kademlia *Node) refreshNodes() {
-	for i := 1; i > 159; i++ {
-		nodeID := generateRefreshNodeValue(i, time.Now().UTC().UnixNano())
+	me := kademlia.RT.me.ID
+
+	for i := 0; i < IDLength*8; i++ {
+		if !kademlia.shouldRefreshBucket(i) {
+			continue
+		}
+
+		nodeID := generateRefreshNodeValue(me, i, time.Now().UTC().UnixNano())
 		contact := NewContact(nodeID, "")
 		kademlia.NodeLookup(&contact)
+		kademlia.markBucketRefreshed(i)
 	}
 }
 
-// JoinNetwork add a target node to the routing table, do a Node Lookup on
-// the current node (not the target) and then refresh all buckets
+// shouldRefreshBucket reports whether bucket i hasn't had a lookup
+// within bucketRefreshWindow.
+func (kademlia *Node) shouldRefreshBucket(i int) bool {
+	kademlia.mu.Lock()
+	defer kademlia.mu.Unlock()
+
+	last, ok := kademlia.bucketRefreshed[i]
+	return !ok || time.Since(last) >= bucketRefreshWindow
+}
+
+// markBucketRefreshed records that bucket i was just refreshed.
+func (kademlia *Node) markBucketRefreshed(i int) {
+	kademlia.mu.Lock()
+	defer kademlia.mu.Unlock()
+
+	kademlia.bucketRefreshed[i] = time.Now()
+}
+
+// refreshLoop periodically calls refreshNodes so buckets that have
+// gone quiet still get refreshed.
+func (kademlia *Node) refreshLoop() {
+	ticker := time.NewTicker(bucketRefreshInterval)
+	for range ticker.C {
+		kademlia.refreshNodes()
+	}
+}
+
+// JoinNetwork adds target to the routing table, does a NodeLookup on
+// the current node (not the target) to populate the buckets nearest to
+// us, and then refreshes every other bucket so a freshly-joined node
+// quickly has live contacts across the whole ID space.
 func (kademlia *Node) JoinNetwork(target Contact) {
+	kademlia.handleAddContact(target)
 
-	kademlia.RT.AddContact(target)
+	me := kademlia.RT.me
+	kademlia.NodeLookup(&me)
 
-	// kademlia.NodeLookup(kademlia.RT.GetMe())
+	kademlia.refreshNodes()
+}
 
-	// kademlia.refreshNodes()
+// Shutdown flushes the node database to disk so liveness history
+// survives a clean restart. Callers that terminate a node gracefully
+// should call this before exiting.
+func (kademlia *Node) Shutdown() {
+	if err := kademlia.db.Close(); err != nil {
+		log.Warn(err)
+	}
 }
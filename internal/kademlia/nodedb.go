@@ -0,0 +1,237 @@
+package kademlia
+
+import (
+	"encoding/gob"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// bondExpiration is how long a previously-pong'd contact is considered
+// still alive without needing to be re-pinged, mirroring the bond
+// expiration window used by Ethereum's discovery node database.
+const bondExpiration = 24 * time.Hour
+
+// nodeDBEvictThreshold is the number of consecutive FIND_NODE/FIND_VALUE
+// failures recorded against a contact before nodedb considers it dead.
+const nodeDBEvictThreshold = 3
+
+// nodeDBCompactTTL is the default age after which an entry that hasn't
+// been seen is dropped during compaction.
+const nodeDBCompactTTL = 7 * 24 * time.Hour
+
+// nodeDBFlushInterval is how often the node database is persisted to
+// disk in the background, so a crash loses at most this much liveness
+// history instead of everything since the last clean shutdown.
+const nodeDBFlushInterval = 5 * time.Minute
+
+// nodeDBEntry is the per-contact liveness record kept in the node
+// database.
+type nodeDBEntry struct {
+	Address   string
+	FirstSeen time.Time
+	LastPing  time.Time
+	LastPong  time.Time
+	FindFails int
+}
+
+// NodeDB is a small persistent store of per-contact liveness metadata
+// (last ping/pong times, consecutive FIND_NODE failures, first-seen
+// timestamp), keyed by NodeID. It lets a node skip re-pinging contacts
+// it has recently heard from, evict contacts that stop responding, and
+// seed its routing table from previously-known live contacts on
+// startup, instead of relying only on the hardcoded rendezvous node.
+type NodeDB struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]*nodeDBEntry
+}
+
+// NewNodeDB opens the node database at path, loading any entries
+// persisted by a previous run. A missing file is not an error: it
+// simply starts empty.
+func NewNodeDB(path string) (*NodeDB, error) {
+	db := &NodeDB{
+		path:    path,
+		entries: make(map[string]*nodeDBEntry),
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return db, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if err := gob.NewDecoder(f).Decode(&db.entries); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+func (db *NodeDB) entry(id string) *nodeDBEntry {
+	e, ok := db.entries[id]
+	if !ok {
+		e = &nodeDBEntry{FirstSeen: time.Now()}
+		db.entries[id] = e
+	}
+	return e
+}
+
+// RecordPing updates the last outbound ping time for id.
+func (db *NodeDB) RecordPing(id, address string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	e := db.entry(id)
+	e.Address = address
+	e.LastPing = time.Now()
+}
+
+// RecordPong updates the last received pong time for id and resets its
+// find-failure count, since a pong proves the contact is alive.
+func (db *NodeDB) RecordPong(id, address string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	e := db.entry(id)
+	e.Address = address
+	e.LastPong = time.Now()
+	e.FindFails = 0
+}
+
+// IsBonded reports whether id has pong'd within bondExpiration, meaning
+// it does not need to be re-pinged yet.
+func (db *NodeDB) IsBonded(id string) bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	e, ok := db.entries[id]
+	if !ok || e.LastPong.IsZero() {
+		return false
+	}
+	return time.Since(e.LastPong) < bondExpiration
+}
+
+// IncFindFail records a FIND_NODE/FIND_VALUE failure for id and reports
+// whether id has now failed nodeDBEvictThreshold times in a row and
+// should be evicted.
+func (db *NodeDB) IncFindFail(id string) bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	e := db.entry(id)
+	e.FindFails++
+	return e.FindFails >= nodeDBEvictThreshold
+}
+
+// ResetFindFail clears the find-failure count for id after it responds
+// successfully to a FIND_NODE/FIND_VALUE RPC.
+func (db *NodeDB) ResetFindFail(id string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.entry(id).FindFails = 0
+}
+
+// LiveContacts returns contacts that have pong'd within bondExpiration,
+// suitable for seeding a routing table on startup.
+func (db *NodeDB) LiveContacts() []Contact {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var contacts []Contact
+	for id, e := range db.entries {
+		if e.LastPong.IsZero() || time.Since(e.LastPong) >= bondExpiration {
+			continue
+		}
+		contacts = append(contacts, NewContact(NewNodeID(id), e.Address))
+	}
+	return contacts
+}
+
+// Compact drops entries that haven't been seen (pinged or pong'd)
+// within ttl.
+func (db *NodeDB) Compact(ttl time.Duration) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	now := time.Now()
+	for id, e := range db.entries {
+		lastSeen := e.LastPong
+		if e.LastPing.After(lastSeen) {
+			lastSeen = e.LastPing
+		}
+		if lastSeen.IsZero() {
+			lastSeen = e.FirstSeen
+		}
+		if now.Sub(lastSeen) >= ttl {
+			delete(db.entries, id)
+		}
+	}
+}
+
+// RunCompaction starts a background goroutine that compacts the
+// database against ttl every interval, until stop is closed.
+func (db *NodeDB) RunCompaction(interval, ttl time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				db.Compact(ttl)
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// RunPersist starts a background goroutine that flushes the database to
+// disk every interval, until stop is closed. This is what actually makes
+// LiveContacts() seeding useful across restarts: without a periodic
+// flush (or a clean Close), the on-disk file NewNodeDB loads from is
+// never written.
+func (db *NodeDB) RunPersist(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := db.persist(); err != nil {
+					log.Warn(err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close flushes the database to disk one last time.
+func (db *NodeDB) Close() error {
+	return db.persist()
+}
+
+// persist writes the database's entries to db.path.
+func (db *NodeDB) persist() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	f, err := os.Create(db.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := gob.NewEncoder(f).Encode(db.entries); err != nil {
+		return err
+	}
+	return nil
+}
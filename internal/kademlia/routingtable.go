@@ -0,0 +1,235 @@
+package kademlia
+
+import "sync"
+
+// IDLength is the length, in bytes, of a NodeID (160 bits).
+const IDLength = 20
+
+// BucketSize is k, the maximum number of live contacts held in a
+// single k-bucket.
+const BucketSize = 20
+
+// replacementCacheSize bounds the number of contacts kept on standby
+// per bucket once that bucket is full, in most-recently-seen order.
+const replacementCacheSize = 10
+
+// AddContactStatus reports what RoutingTable.AddContact did with a
+// contact.
+type AddContactStatus int
+
+const (
+	// Rejected means the contact was not stored anywhere, e.g. because
+	// it was the routing table's own contact.
+	Rejected AddContactStatus = iota
+	// Added means the contact now lives in its bucket's live list.
+	Added
+	// Cached means the contact's bucket was full, so it was pushed
+	// onto that bucket's replacement cache instead.
+	Cached
+)
+
+// bucket is a single k-bucket: up to BucketSize live contacts in LRU
+// order (index 0 = least-recently-seen, last = most-recently-seen)
+// plus a bounded replacement cache of contacts seen while the bucket
+// was full.
+type bucket struct {
+	contacts     []Contact
+	replacements []Contact
+}
+
+// touch moves contact to the most-recently-seen end of the bucket, if
+// present.
+func (b *bucket) touch(contact Contact) {
+	for i, existing := range b.contacts {
+		if existing.ID.Equals(contact.ID) {
+			b.contacts = append(append(b.contacts[:i], b.contacts[i+1:]...), existing)
+			return
+		}
+	}
+}
+
+// pushReplacement adds contact to the bucket's replacement cache,
+// most-recently-seen last, bounded to replacementCacheSize.
+func (b *bucket) pushReplacement(contact Contact) {
+	for i, existing := range b.replacements {
+		if existing.ID.Equals(contact.ID) {
+			b.replacements = append(append(b.replacements[:i], b.replacements[i+1:]...), contact)
+			return
+		}
+	}
+
+	b.replacements = append(b.replacements, contact)
+	if len(b.replacements) > replacementCacheSize {
+		b.replacements = b.replacements[len(b.replacements)-replacementCacheSize:]
+	}
+}
+
+// popReplacement removes and returns the most-recently-seen
+// replacement-cache entry, if any.
+func (b *bucket) popReplacement() (Contact, bool) {
+	if len(b.replacements) == 0 {
+		return Contact{}, false
+	}
+	promoted := b.replacements[len(b.replacements)-1]
+	b.replacements = b.replacements[:len(b.replacements)-1]
+	return promoted, true
+}
+
+// RoutingTable holds this node's view of the network as 160 k-buckets,
+// one per bit of NodeID distance from me.
+type RoutingTable struct {
+	me      Contact
+	buckets [IDLength * 8]*bucket
+
+	mu sync.Mutex
+}
+
+// NewRoutingTable creates a RoutingTable for a node identified by me.
+func NewRoutingTable(me Contact) *RoutingTable {
+	rt := &RoutingTable{me: me}
+	for i := range rt.buckets {
+		rt.buckets[i] = &bucket{}
+	}
+	return rt
+}
+
+// bucketFor returns the bucket that id falls into, based on its XOR
+// distance from me.
+func (rt *RoutingTable) bucketFor(id *NodeID) *bucket {
+	return rt.buckets[bucketIndex(xorDistance(rt.me.ID, id))]
+}
+
+// AddContact adds contact to its bucket's live list if there is room,
+// moving it to the most-recently-seen position if it was already
+// known. If the bucket is full, contact is pushed onto that bucket's
+// replacement cache instead, to be promoted later if a live entry goes
+// bad (see Replace).
+func (rt *RoutingTable) AddContact(contact Contact) AddContactStatus {
+	if contact.ID.Equals(rt.me.ID) {
+		return Rejected
+	}
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	b := rt.bucketFor(contact.ID)
+
+	for _, existing := range b.contacts {
+		if existing.ID.Equals(contact.ID) {
+			b.touch(contact)
+			return Added
+		}
+	}
+
+	if len(b.contacts) < BucketSize {
+		b.contacts = append(b.contacts, contact)
+		return Added
+	}
+
+	b.pushReplacement(contact)
+	return Cached
+}
+
+// LeastRecentlySeen returns the least-recently-seen live contact in the
+// bucket that id falls into, if that bucket has any live contacts.
+// This is the contact callers should ping before evicting it to make
+// room for a new one.
+func (rt *RoutingTable) LeastRecentlySeen(id *NodeID) (Contact, bool) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	b := rt.bucketFor(id)
+	if len(b.contacts) == 0 {
+		return Contact{}, false
+	}
+	return b.contacts[0], true
+}
+
+// Touch marks contact as just seen, moving it to the most-recently-seen
+// position in its bucket.
+func (rt *RoutingTable) Touch(contact Contact) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	rt.bucketFor(contact.ID).touch(contact)
+}
+
+// Replace evicts contact, which has just failed to respond to a
+// liveness ping, from its bucket and promotes the most-recently-seen
+// replacement-cache entry into its place, if there is one.
+func (rt *RoutingTable) Replace(contact Contact) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	b := rt.bucketFor(contact.ID)
+	for i, existing := range b.contacts {
+		if existing.ID.Equals(contact.ID) {
+			b.contacts = append(b.contacts[:i], b.contacts[i+1:]...)
+			break
+		}
+	}
+
+	if promoted, ok := b.popReplacement(); ok {
+		b.contacts = append(b.contacts, promoted)
+	}
+}
+
+// RemoveContact evicts contact from its bucket outright, with no
+// promotion from the replacement cache. Used when a contact is known
+// dead rather than merely unresponsive to one liveness check.
+func (rt *RoutingTable) RemoveContact(contact Contact) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	b := rt.bucketFor(contact.ID)
+	for i, existing := range b.contacts {
+		if existing.ID.Equals(contact.ID) {
+			b.contacts = append(b.contacts[:i], b.contacts[i+1:]...)
+			return
+		}
+	}
+}
+
+// FindClosestContacts returns up to count contacts from the routing
+// table, sorted by XOR distance to target (closest first).
+func (rt *RoutingTable) FindClosestContacts(target *NodeID, count int) []Contact {
+	rt.mu.Lock()
+	var candidates []Contact
+	for _, b := range rt.buckets {
+		candidates = append(candidates, b.contacts...)
+	}
+	rt.mu.Unlock()
+
+	sortByDistance(candidates, target)
+	if len(candidates) > count {
+		candidates = candidates[:count]
+	}
+	return candidates
+}
+
+// xorDistance returns the byte-wise XOR distance between a and b.
+func xorDistance(a, b *NodeID) []byte {
+	distance := make([]byte, IDLength)
+	for i := 0; i < IDLength; i++ {
+		distance[i] = a[i] ^ b[i]
+	}
+	return distance
+}
+
+// bucketIndex returns the bucket that a given XOR distance falls into:
+// the bit position (0 = least significant) of the most significant set
+// bit in distance.
+func bucketIndex(distance []byte) int {
+	for bytePos := 0; bytePos < IDLength; bytePos++ {
+		b := distance[bytePos]
+		if b == 0 {
+			continue
+		}
+		for bit := 0; bit < 8; bit++ {
+			if b&(0x80>>uint(bit)) != 0 {
+				return (IDLength-1-bytePos)*8 + (7 - bit)
+			}
+		}
+	}
+	return 0
+}
@@ -1,8 +1,13 @@
 package kademlia
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 
 	"github.com/viktorfrom/d7024e-kademlia/pkg/randarr"
 )
@@ -63,21 +68,215 @@ func validateRPCType(rpc RPCType) error {
 	return errors.New(errWrongType)
 }
 
-// MarshalRPC serializes the RPC struct and returns the result as a byte array
-func MarshalRPC(rpc RPC) ([]byte, error) {
-	var data []byte
-	data, err := json.Marshal(rpc)
+// WireCodec encodes and decodes RPCs for transport over the network.
+// JSONCodec and BinaryCodec implement it; SelectCodec picks one from a
+// peer's protocol version, so old and new nodes can coexist during a
+// rollout by speaking whichever codec the peer on the wire expects.
+type WireCodec interface {
+	Encode(rpc RPC) ([]byte, error)
+	Decode(data []byte) (*RPC, error)
+}
+
+// JSONCodec is the original, human-readable wire codec: it marshals
+// the RPC struct straight to JSON, field names and all.
+type JSONCodec struct{}
 
-	return data, err
+// Encode implements WireCodec.
+func (JSONCodec) Encode(rpc RPC) ([]byte, error) {
+	return json.Marshal(rpc)
 }
 
-// UnmarshalRPC deserializes the given byte array and returns an RPC
-func UnmarshalRPC(data []byte) (*RPC, error) {
+// Decode implements WireCodec.
+func (JSONCodec) Decode(data []byte) (*RPC, error) {
 	rpc := RPC{}
-	err := json.Unmarshal(data, &rpc)
+	if err := json.Unmarshal(data, &rpc); err != nil {
+		return nil, err
+	}
+	return &rpc, nil
+}
+
+const (
+	// wireMagic tags the start of every BinaryCodec packet so a
+	// misdirected or truncated UDP datagram is rejected instead of
+	// silently misparsed.
+	wireMagic = "KAD1"
+
+	// wireVersion is the current BinaryCodec protocol version. It is
+	// carried in every packet so peers running different versions can
+	// be told apart instead of failing an opaque decode.
+	wireVersion = 1
+)
+
+// wireHeader is the fixed-size preamble of a BinaryCodec packet:
+// magic, protocol version, RPC type, the 20-byte sender NodeID, the
+// 20-byte RPC ID, and the length of the gob-encoded payload that
+// follows it. Putting the sender and RPC IDs in the header (rather
+// than in the payload) lets a receiver identify and dedup a packet
+// without having to decode its payload first.
+type wireHeader struct {
+	Magic    [4]byte
+	Version  uint8
+	Type     uint8
+	SenderID [IDLength]byte
+	RPCID    [IDLength]byte
+	Length   uint32
+}
+
+const wireHeaderSize = 4 + 1 + 1 + IDLength + IDLength + 4
+
+// BinaryCodec is a compact length-prefixed wire format: a fixed header
+// (magic, protocol version, RPC type byte, sender NodeID, RPC ID,
+// payload length) followed by the RPC's Payload gob-encoded. It avoids
+// JSON's per-packet field names and makes truncated UDP datagrams
+// detectable instead of silently misparsed.
+type BinaryCodec struct{}
+
+// Encode implements WireCodec.
+func (BinaryCodec) Encode(rpc RPC) ([]byte, error) {
+	if rpc.Type == nil || rpc.ID == nil || rpc.SenderIP == nil {
+		return nil, errors.New(errWrongType)
+	}
+	typeByte, err := rpcTypeByte(*rpc.Type)
 	if err != nil {
 		return nil, err
 	}
 
-	return &rpc, nil
-}
\ No newline at end of file
+	senderID, err := decodeFixedHex(*rpc.SenderIP, IDLength)
+	if err != nil {
+		return nil, fmt.Errorf("binary rpc: sender id: %w", err)
+	}
+	rpcID, err := decodeFixedHex(*rpc.ID, IDLength)
+	if err != nil {
+		return nil, fmt.Errorf("binary rpc: rpc id: %w", err)
+	}
+
+	var payload bytes.Buffer
+	if rpc.Payload != nil {
+		if err := gob.NewEncoder(&payload).Encode(rpc.Payload); err != nil {
+			return nil, err
+		}
+	}
+
+	header := wireHeader{
+		Version: wireVersion,
+		Type:    typeByte,
+		Length:  uint32(payload.Len()),
+	}
+	copy(header.Magic[:], wireMagic)
+	copy(header.SenderID[:], senderID)
+	copy(header.RPCID[:], rpcID)
+
+	var out bytes.Buffer
+	if err := binary.Write(&out, binary.BigEndian, header); err != nil {
+		return nil, err
+	}
+	out.Write(payload.Bytes())
+
+	return out.Bytes(), nil
+}
+
+// Decode implements WireCodec.
+func (BinaryCodec) Decode(data []byte) (*RPC, error) {
+	if len(data) < wireHeaderSize {
+		return nil, fmt.Errorf("binary rpc: packet too short (%d bytes)", len(data))
+	}
+
+	var header wireHeader
+	if err := binary.Read(bytes.NewReader(data[:wireHeaderSize]), binary.BigEndian, &header); err != nil {
+		return nil, err
+	}
+	if string(header.Magic[:]) != wireMagic {
+		return nil, errors.New("binary rpc: bad magic")
+	}
+	if header.Version != wireVersion {
+		return nil, fmt.Errorf("binary rpc: unsupported protocol version %d", header.Version)
+	}
+
+	body := data[wireHeaderSize:]
+	if uint32(len(body)) != header.Length {
+		return nil, fmt.Errorf("binary rpc: payload length mismatch: header says %d, got %d", header.Length, len(body))
+	}
+
+	rpcType, err := rpcTypeFromByte(header.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload Payload
+	if header.Length > 0 {
+		if err := gob.NewDecoder(bytes.NewReader(body)).Decode(&payload); err != nil {
+			return nil, err
+		}
+	}
+
+	senderID := hex.EncodeToString(header.SenderID[:])
+	rpcID := hex.EncodeToString(header.RPCID[:])
+
+	return &RPC{Type: &rpcType, Payload: &payload, ID: &rpcID, SenderIP: &senderID}, nil
+}
+
+// rpcTypeByte maps an RPCType to the single byte BinaryCodec carries
+// in its header.
+func rpcTypeByte(t RPCType) (uint8, error) {
+	for i, candidate := range rpcTypes {
+		if candidate == t {
+			return uint8(i), nil
+		}
+	}
+	return 0, errors.New(errWrongType)
+}
+
+// rpcTypeFromByte is the inverse of rpcTypeByte.
+func rpcTypeFromByte(b uint8) (RPCType, error) {
+	if int(b) >= len(rpcTypes) {
+		return "", errors.New(errWrongType)
+	}
+	return rpcTypes[b], nil
+}
+
+// decodeFixedHex hex-decodes s and requires it to be exactly size
+// bytes. The fixed-width wire header has no room to silently reinterpret
+// a short or long ID: padding or truncating it would put a different
+// value on the wire than the one the caller generated, breaking
+// response correlation/dedup without any error to show for it, so a
+// width mismatch is reported instead.
+func decodeFixedHex(s string, size int) ([]byte, error) {
+	decoded, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) != size {
+		return nil, fmt.Errorf("expected %d-byte hex string, got %d bytes", size, len(decoded))
+	}
+	return decoded, nil
+}
+
+// SelectCodec is the version-negotiation decision point a Network
+// should call when it learns a peer's advertised protocol version (out
+// of band, e.g. during a handshake, or via wireHeader.Version on the
+// first packet received from that peer): a peer that speaks wireVersion
+// gets BinaryCodec, anything older falls back to JSONCodec so the two
+// can still talk. Network's construction-time codec selection is not
+// part of this chunk (there is no network.go in this tree), so nothing
+// calls this yet, but it is the concrete integration point that should.
+func SelectCodec(peerVersion uint8) WireCodec {
+	if peerVersion >= wireVersion {
+		return BinaryCodec{}
+	}
+	return JSONCodec{}
+}
+
+// MarshalRPC serializes the RPC struct using the JSON wire codec and
+// returns the result as a byte array. Kept as the default, backwards
+// compatible entry point; callers that need a specific codec (e.g.
+// Network, which should select one via SelectCodec at construction)
+// should use that codec's Encode directly.
+func MarshalRPC(rpc RPC) ([]byte, error) {
+	return JSONCodec{}.Encode(rpc)
+}
+
+// UnmarshalRPC deserializes the given byte array using the JSON wire
+// codec and returns an RPC. See MarshalRPC.
+func UnmarshalRPC(data []byte) (*RPC, error) {
+	return JSONCodec{}.Decode(data)
+}